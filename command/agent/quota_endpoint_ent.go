@@ -0,0 +1,49 @@
+//go:build ent
+// +build ent
+
+package agent
+
+import (
+	"net/http"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// registerEnterpriseHTTPHandlers registers this file's enterprise-only HTTP
+// routes with the agent's mux. It is called from
+// HTTPServer.registerHandlers alongside the registration of every other
+// route (/v1/job, /v1/node, ...), so /v1/quota/check is reachable the same
+// way they are rather than only existing as an unregistered handler method.
+func registerEnterpriseHTTPHandlers(s *HTTPServer, mux *http.ServeMux) {
+	mux.HandleFunc("/v1/quota/check", s.wrap(s.QuotaCheckRequest))
+}
+
+// QuotaCheckRequest handles PUT /v1/quota/check. It accepts a job
+// definition and reports whether it would fit within the quotas governing
+// its namespace, without submitting it for scheduling.
+func (s *HTTPServer) QuotaCheckRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != http.MethodPut && req.Method != http.MethodPost {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	var jobReq api.JobRegisterRequest
+	if err := decodeBody(req, &jobReq); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	job := ApiJobToStructJob(jobReq.Job)
+
+	args := structs.QuotaCheckRequest{
+		Job: job,
+	}
+	s.parseWriteRequest(req, &args.WriteRequest)
+
+	var out structs.QuotaCheckResponse
+	if err := s.agent.RPC("Quota.Check", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	return out, nil
+}
@@ -0,0 +1,62 @@
+//go:build ent
+// +build ent
+
+package nomad
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// enterpriseFSMApply dispatches msgType to this package's enterprise-only
+// Raft apply handlers, reporting handled=false for any type it does not
+// own. nomadFSM.Apply's default case calls this (and the equivalent
+// dispatcher for every other enterprise feature) so the quota message
+// types below are actually reachable through raftApply, rather than only
+// existing as unregistered methods.
+func enterpriseFSMApply(n *nomadFSM, msgType structs.MessageType, buf []byte, index uint64) (result interface{}, handled bool) {
+	switch msgType {
+	case structs.QuotaUsageUpsertRequestType:
+		return n.applyQuotaUsageUpsert(buf, index), true
+	case structs.ClusterQuotaUsageUpsertRequestType:
+		return n.applyClusterQuotaUsageUpsert(buf, index), true
+	default:
+		return nil, false
+	}
+}
+
+// applyQuotaUsageUpsert applies a QuotaUsageUpsertRequest, persisting
+// corrective QuotaUsage updates produced by the background quota
+// reconciler. It is dispatched by enterpriseFSMApply for
+// structs.QuotaUsageUpsertRequestType.
+func (n *nomadFSM) applyQuotaUsageUpsert(buf []byte, index uint64) interface{} {
+	var req structs.QuotaUsageUpsertRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode QuotaUsageUpsertRequest: %v", err))
+	}
+
+	if err := n.state.UpsertQuotaUsages(index, req.Usages); err != nil {
+		n.logger.Error("UpsertQuotaUsages failed", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// applyClusterQuotaUsageUpsert applies a ClusterQuotaUsageUpsertRequest,
+// persisting corrective ClusterQuotaUsage updates. It is dispatched by
+// enterpriseFSMApply for structs.ClusterQuotaUsageUpsertRequestType.
+func (n *nomadFSM) applyClusterQuotaUsageUpsert(buf []byte, index uint64) interface{} {
+	var req structs.ClusterQuotaUsageUpsertRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode ClusterQuotaUsageUpsertRequest: %v", err))
+	}
+
+	if err := n.state.UpsertClusterQuotaUsages(index, req.Usages); err != nil {
+		n.logger.Error("UpsertClusterQuotaUsages failed", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+//go:build ent
+// +build ent
+
+package nomad
+
+import "sync"
+
+// quotaReconcilers tracks each server's running QuotaReconciler, keyed by
+// the *Server it belongs to. This enterprise feature only needs to add one
+// field's worth of state to Server, so it is tracked here instead of
+// editing the Server struct itself, the same way an unexported
+// package-level registry is used elsewhere in Go to extend a type that
+// belongs to a different file without modifying it.
+var (
+	quotaReconcilersMu sync.Mutex
+	quotaReconcilers   = make(map[*Server]*QuotaReconciler)
+)
+
+// establishEnterpriseLeadership starts enterprise-only background
+// processes that must run only on the current leader. It is called from
+// establishLeadership once this server has been elected leader.
+func (s *Server) establishEnterpriseLeadership() {
+	quotaReconcilersMu.Lock()
+	defer quotaReconcilersMu.Unlock()
+
+	if _, ok := quotaReconcilers[s]; ok {
+		return
+	}
+	r := NewQuotaReconciler(s)
+	quotaReconcilers[s] = r
+	go r.Run()
+}
+
+// revokeEnterpriseLeadership stops enterprise-only background processes
+// started by establishEnterpriseLeadership. It is called from
+// revokeLeadership when this server steps down or loses leadership.
+func (s *Server) revokeEnterpriseLeadership() {
+	quotaReconcilersMu.Lock()
+	defer quotaReconcilersMu.Unlock()
+
+	if r, ok := quotaReconcilers[s]; ok {
+		r.Stop()
+		delete(quotaReconcilers, s)
+	}
+}
+
+// enterpriseQuotaAllocChanged enqueues namespace for a targeted quota
+// recompute. It is called from the alloc-apply path whenever an
+// allocation bound to a quota-governed namespace starts or stops, so drift
+// is corrected promptly instead of waiting for the reconciler's next full
+// resync.
+func (s *Server) enterpriseQuotaAllocChanged(namespace string) {
+	quotaReconcilersMu.Lock()
+	r, ok := quotaReconcilers[s]
+	quotaReconcilersMu.Unlock()
+
+	if !ok {
+		return
+	}
+	r.EnqueueNamespace(namespace)
+}
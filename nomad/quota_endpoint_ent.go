@@ -0,0 +1,54 @@
+//go:build ent
+// +build ent
+
+package nomad
+
+import (
+	"net/rpc"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/scheduler"
+)
+
+// Quota endpoint is used for CRUD on quota specs as well as dry-run
+// admission checks against them.
+type Quota struct {
+	srv *Server
+}
+
+// registerEnterpriseEndpoints registers this file's enterprise-only RPC
+// endpoints with the server's RPC dispatcher. It is called from
+// Server.setupRpcServer alongside the registration of every other endpoint
+// (Job, Node, Alloc, ...), so Quota.Check is reachable the same way they
+// are rather than only existing as an unregistered method.
+func registerEnterpriseEndpoints(srv *Server, rpcServer *rpc.Server) error {
+	return rpcServer.Register(&Quota{srv: srv})
+}
+
+// Check evaluates whether the given job would fit within the quotas
+// governing its namespace, without going through the scheduler. It powers
+// the PUT /v1/quota/check HTTP endpoint so CI/CD can validate a submission
+// before plan/run.
+func (q *Quota) Check(args *structs.QuotaCheckRequest, reply *structs.QuotaCheckResponse) error {
+	if done, err := q.srv.forward("Quota.Check", args, args, reply); done {
+		return err
+	}
+
+	if args.Job == nil {
+		return structs.NewErrRPCCoded(400, "missing job for quota check")
+	}
+
+	snap, err := q.srv.fsm.State().Snapshot()
+	if err != nil {
+		return err
+	}
+
+	resp, err := scheduler.CheckJobQuota(snap, args.Job)
+	if err != nil {
+		return err
+	}
+
+	*reply = *resp
+	q.srv.setQueryMeta(&reply.QueryMeta)
+	return nil
+}
@@ -0,0 +1,464 @@
+//go:build ent
+// +build ent
+
+package nomad
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// defaultQuotaResyncPeriod is how often the QuotaReconciler performs a full
+// resync of every quota, as a backstop against missed or coalesced events.
+// Modeled after the k8s ResourceQuotaController's default resync interval.
+const defaultQuotaResyncPeriod = 5 * time.Minute
+
+// quotaReconcileWorkers is the number of goroutines draining the dirty
+// queue. Reconciliation is cheap relative to scheduling, so a small fixed
+// pool is sufficient.
+const quotaReconcileWorkers = 4
+
+// QuotaDrift describes the difference observed between a quota's stored
+// usage and usage recomputed from the current set of non-terminal
+// allocations, for a single limit (keyed by QuotaLimit.Hash).
+type QuotaDrift struct {
+	Stored     *structs.QuotaLimit
+	Recomputed *structs.QuotaLimit
+}
+
+// quotaReconcileStatus is the last known reconciliation result for a single
+// quota, surfaced on the quota status endpoint.
+type quotaReconcileStatus struct {
+	lastReconciled time.Time
+	drift          map[string]*QuotaDrift
+}
+
+// QuotaReconciler periodically recomputes QuotaUsage from the set of
+// non-terminal allocations bound to each quota and corrects any drift from
+// incremental accounting (UpdateUsageFromPlan), which can fall out of sync
+// with reality on leader failover, a lost plan apply, or a bug. It is
+// event-driven -- an allocation starting or stopping enqueues a targeted
+// recompute of the namespaces it touches -- with a periodic full resync as
+// a backstop, mirroring the k8s ResourceQuotaController.
+type QuotaReconciler struct {
+	srv          *Server
+	logger       log.Logger
+	resyncPeriod time.Duration
+
+	queue   chan string // namespace names pending reconciliation
+	pending map[string]struct{}
+
+	mu     sync.Mutex
+	status map[string]*quotaReconcileStatus // keyed by quota name
+
+	// clusterQuotaMu serializes the read-modify-write cycle in
+	// reconcileClusterQuotas. Two namespaces matching the same
+	// ClusterQuotaSpec can be reconciled concurrently by separate workers;
+	// without this lock each would read the same base ClusterQuotaUsage and
+	// raftApply a full updated copy, so the last writer would silently drop
+	// the other's contribution.
+	clusterQuotaMu sync.Mutex
+
+	shutdownCh chan struct{}
+}
+
+// NewQuotaReconciler creates a reconciler bound to the given server. Call
+// Run to start its workers and periodic resync loop.
+func NewQuotaReconciler(srv *Server) *QuotaReconciler {
+	return &QuotaReconciler{
+		srv:          srv,
+		logger:       srv.logger.Named("quota_reconciler"),
+		resyncPeriod: defaultQuotaResyncPeriod,
+		queue:        make(chan string, 1024),
+		pending:      make(map[string]struct{}),
+		status:       make(map[string]*quotaReconcileStatus),
+		shutdownCh:   make(chan struct{}),
+	}
+}
+
+// Run starts the worker pool and the periodic full-resync ticker. It blocks
+// until Stop is called.
+func (r *QuotaReconciler) Run() {
+	for i := 0; i < quotaReconcileWorkers; i++ {
+		go r.worker()
+	}
+
+	ticker := time.NewTicker(r.resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.fullResync()
+		case <-r.shutdownCh:
+			return
+		}
+	}
+}
+
+// Stop halts the reconciler's workers and resync ticker.
+func (r *QuotaReconciler) Stop() {
+	close(r.shutdownCh)
+}
+
+// EnqueueNamespace schedules namespace for a targeted recompute. Callers
+// trigger this when an allocation bound to a quota starts or stops, so
+// drift is corrected promptly rather than waiting for the next full resync.
+func (r *QuotaReconciler) EnqueueNamespace(namespace string) {
+	r.mu.Lock()
+	if _, dup := r.pending[namespace]; dup {
+		r.mu.Unlock()
+		return
+	}
+	r.pending[namespace] = struct{}{}
+	r.mu.Unlock()
+
+	select {
+	case r.queue <- namespace:
+	case <-r.shutdownCh:
+	}
+}
+
+// fullResync enqueues every namespace bound to a quota, as a backstop in
+// case a targeted enqueue was dropped (e.g. during leader failover). A
+// namespace with no quota of its own but matched by a ClusterQuotaSpec
+// selector is also enqueued, since reconcileNamespace reconciles cluster
+// quota membership independently of whether the namespace has its own
+// quota.
+func (r *QuotaReconciler) fullResync() {
+	state := r.srv.fsm.State()
+	iter, err := state.Namespaces(nil)
+	if err != nil {
+		r.logger.Error("failed to list namespaces for quota resync", "error", err)
+		return
+	}
+
+	clusterQuotas, err := state.ClusterQuotaSpecs(nil)
+	if err != nil {
+		r.logger.Error("failed to list cluster quotas for quota resync", "error", err)
+		clusterQuotas = nil
+	}
+
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		ns := raw.(*structs.Namespace)
+		if ns.Quota != "" {
+			r.EnqueueNamespace(ns.Name)
+			continue
+		}
+		for _, cq := range clusterQuotas {
+			if cq.NamespaceSelector.Matches(ns) {
+				r.EnqueueNamespace(ns.Name)
+				break
+			}
+		}
+	}
+}
+
+// worker drains the dirty queue, reconciling one namespace at a time.
+func (r *QuotaReconciler) worker() {
+	for {
+		select {
+		case ns := <-r.queue:
+			r.mu.Lock()
+			delete(r.pending, ns)
+			r.mu.Unlock()
+
+			if err := r.reconcileNamespace(ns); err != nil {
+				r.logger.Error("failed to reconcile quota usage", "namespace", ns, "error", err)
+			}
+		case <-r.shutdownCh:
+			return
+		}
+	}
+}
+
+// reconcileNamespace recomputes the quota usage attributable to namespace
+// from scratch by walking its non-terminal allocations, diffs the result
+// against the stored QuotaUsage, and Raft-applies a corrective update when
+// they differ. Cluster quota membership is reconciled independently of
+// whether namespace has a quota of its own, since a namespace with no
+// QuotaSpec (or an undrifted one) can still match a ClusterQuotaSpec
+// selector and owe it a contribution.
+func (r *QuotaReconciler) reconcileNamespace(namespace string) error {
+	state := r.srv.fsm.State()
+
+	ns, err := state.NamespaceByName(nil, namespace)
+	if err != nil || ns == nil {
+		return err
+	}
+
+	// Every ClusterQuotaSpec whose NamespaceSelector matches this namespace
+	// aggregates usage across namespaces, so its Total must be kept in sync
+	// whenever this namespace's contribution could have changed. There is no
+	// plan-apply hook in this tree to update it incrementally, so the
+	// reconcile loop -- which already walks this namespace's allocations --
+	// is where ClusterQuotaUsage.ApplyNamespaceUsage's diff-and-apply keeps
+	// Total correct. This runs unconditionally, not only when the
+	// namespace's own quota drifts, since membership in a cluster quota does
+	// not require the namespace to have (or drift from) a quota of its own.
+	if err := r.reconcileClusterQuotas(ns); err != nil {
+		r.logger.Error("failed to reconcile cluster quota usage", "namespace", namespace, "error", err)
+	}
+
+	if ns.Quota == "" {
+		return nil
+	}
+
+	usage, err := state.QuotaUsageByName(nil, ns.Quota)
+	if err != nil {
+		return err
+	}
+	if usage == nil {
+		usage = &structs.QuotaUsage{Name: ns.Quota}
+	}
+
+	recomputed, err := r.recomputeUsage(ns.Quota)
+	if err != nil {
+		return err
+	}
+
+	drift := diffUsage(usage.Used, recomputed)
+
+	r.mu.Lock()
+	r.status[ns.Quota] = &quotaReconcileStatus{
+		lastReconciled: time.Now(),
+		drift:          drift,
+	}
+	r.mu.Unlock()
+
+	if len(drift) == 0 {
+		return nil
+	}
+
+	r.logger.Warn("corrected quota usage drift", "quota", ns.Quota, "dimensions", len(drift))
+
+	update := &structs.QuotaUsage{
+		Name: ns.Quota,
+		Used: recomputed,
+	}
+	_, _, err = r.srv.raftApply(structs.QuotaUsageUpsertRequestType, &structs.QuotaUsageUpsertRequest{
+		Usages: []*structs.QuotaUsage{update},
+	})
+	return err
+}
+
+// reconcileClusterQuotas recomputes namespace's contribution to every
+// ClusterQuotaSpec it matches and applies the diff to that spec's
+// ClusterQuotaUsage via ApplyNamespaceUsage, Raft-applying the result.
+//
+// The whole read-modify-write cycle is serialized by clusterQuotaMu: two
+// namespaces matching the same ClusterQuotaSpec can be reconciled
+// concurrently by separate workers, and without this lock both would read
+// the same base ClusterQuotaUsage and raftApply a full updated copy, with
+// the last write silently dropping the other namespace's contribution.
+func (r *QuotaReconciler) reconcileClusterQuotas(namespace *structs.Namespace) error {
+	r.clusterQuotaMu.Lock()
+	defer r.clusterQuotaMu.Unlock()
+
+	state := r.srv.fsm.State()
+
+	clusterQuotas, err := state.ClusterQuotaSpecs(nil)
+	if err != nil {
+		return err
+	}
+
+	var updates []*structs.ClusterQuotaUsage
+	for _, cq := range clusterQuotas {
+		if !cq.NamespaceSelector.Matches(namespace) {
+			continue
+		}
+
+		usage, err := state.ClusterQuotaUsageByName(nil, cq.Name)
+		if err != nil {
+			return err
+		}
+		if usage == nil {
+			usage = &structs.ClusterQuotaUsage{Name: cq.Name}
+		}
+
+		contribution, err := r.recomputeUsageForLimits(namespace, cq.LimitsMap())
+		if err != nil {
+			return err
+		}
+
+		usage.ApplyNamespaceUsage(namespace.Name, contribution)
+		updates = append(updates, usage)
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	_, _, err = r.srv.raftApply(structs.ClusterQuotaUsageUpsertRequestType, &structs.ClusterQuotaUsageUpsertRequest{
+		Usages: updates,
+	})
+	return err
+}
+
+// recomputeUsage rebuilds quotaName's usage from scratch. A QuotaSpec may be
+// referenced by more than one namespace (see structs.QuotaSpec), and its
+// QuotaUsage is a single aggregate across all of them, so every namespace
+// bound to it must contribute -- recomputing from only the namespace that
+// happened to trigger this reconcile would silently erase every other
+// namespace's share when the result is Raft-applied.
+func (r *QuotaReconciler) recomputeUsage(quotaName string) (map[string]*structs.QuotaLimit, error) {
+	state := r.srv.fsm.State()
+
+	quota, err := state.QuotaSpecByName(nil, quotaName)
+	if err != nil || quota == nil {
+		return nil, err
+	}
+
+	namespaces, err := r.namespacesByQuota(quotaName)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := quota.LimitsMap()
+	used := make(map[string]*structs.QuotaLimit, len(limits))
+	for hash, l := range limits {
+		used[hash] = &structs.QuotaLimit{Region: l.Region, Scope: l.Scope, Hash: l.Hash}
+	}
+
+	for _, ns := range namespaces {
+		contribution, err := r.recomputeUsageForLimits(ns, limits)
+		if err != nil {
+			return nil, err
+		}
+		for hash, c := range contribution {
+			if acc, ok := used[hash]; ok {
+				acc.AddResource(c.RegionLimit)
+				acc.AddCounts(c.Counts)
+			}
+		}
+	}
+
+	return used, nil
+}
+
+// namespacesByQuota returns every namespace whose Quota references
+// quotaName, since a QuotaSpec can be shared by multiple namespaces.
+func (r *QuotaReconciler) namespacesByQuota(quotaName string) ([]*structs.Namespace, error) {
+	state := r.srv.fsm.State()
+
+	iter, err := state.Namespaces(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaces []*structs.Namespace
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		ns := raw.(*structs.Namespace)
+		if ns.Quota == quotaName {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces, nil
+}
+
+// recomputeUsageForLimits walks every non-terminal allocation in namespace
+// and rebuilds usage against the given limits (either a QuotaSpec's own
+// limits or a ClusterQuotaSpec's) from scratch, using the same
+// combinedResources accounting the scheduler's QuotaIterator relies on
+// incrementally.
+func (r *QuotaReconciler) recomputeUsageForLimits(namespace *structs.Namespace, limits map[string]*structs.QuotaLimit) (map[string]*structs.QuotaLimit, error) {
+	state := r.srv.fsm.State()
+
+	allocs, err := state.AllocsByNamespace(nil, namespace.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]*structs.QuotaLimit, len(limits))
+	for hash, l := range limits {
+		used[hash] = &structs.QuotaLimit{Region: l.Region, Scope: l.Scope, Hash: l.Hash}
+	}
+
+	// seenGroups tracks which (job, task group) pairs have already been
+	// credited toward QuotaCountTaskGroups for a given limit, since that
+	// dimension counts distinct groups rather than allocations.
+	seenGroups := make(map[string]map[string]bool)
+
+	for _, alloc := range allocs {
+		if alloc.TerminalStatus() {
+			continue
+		}
+		tg := alloc.Job.LookupTaskGroup(alloc.TaskGroup)
+		if tg == nil {
+			continue
+		}
+		allocResources := structs.CombinedTaskGroupResources(tg)
+		allocCounts := structs.CombinedTaskGroupCounts(tg)
+		groupKey := alloc.Job.ID + "/" + tg.Name
+
+		for hash, limit := range used {
+			if limit.Region != "" && limit.Region != alloc.Job.Region {
+				continue
+			}
+			if !limit.Scope.Matches(alloc.Job, tg, namespace) {
+				continue
+			}
+			limit.AddResource(allocResources)
+			limit.AddCounts(allocCounts)
+
+			if seenGroups[hash] == nil {
+				seenGroups[hash] = make(map[string]bool)
+			}
+			if !seenGroups[hash][groupKey] {
+				seenGroups[hash][groupKey] = true
+				limit.AddCounts(map[string]int{structs.QuotaCountTaskGroups: 1})
+			}
+		}
+	}
+
+	return used, nil
+}
+
+// diffUsage compares recomputed usage against the stored usage and returns
+// the limits (keyed by Hash) whose resource or count totals disagree.
+func diffUsage(stored, recomputed map[string]*structs.QuotaLimit) map[string]*QuotaDrift {
+	drift := make(map[string]*QuotaDrift)
+	for hash, want := range recomputed {
+		have := stored[hash]
+		if have == nil || !limitUsageEqual(have, want) {
+			drift[hash] = &QuotaDrift{Stored: have, Recomputed: want}
+		}
+	}
+	return drift
+}
+
+// limitUsageEqual reports whether two limits carry the same observed usage.
+func limitUsageEqual(a, b *structs.QuotaLimit) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !a.RegionLimit.Equal(b.RegionLimit) {
+		return false
+	}
+	if len(a.Counts) != len(b.Counts) {
+		return false
+	}
+	for k, v := range a.Counts {
+		if b.Counts[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Status returns the last reconciliation time and observed drift for the
+// named quota, for display on the quota status endpoint.
+func (r *QuotaReconciler) Status(quota string) (lastReconciled time.Time, drift map[string]*QuotaDrift, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.status[quota]
+	if !ok {
+		return time.Time{}, nil, false
+	}
+	return s.lastReconciled, s.drift, true
+}
@@ -0,0 +1,78 @@
+//go:build ent
+// +build ent
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// quotaUsageTable and clusterQuotaUsageTable are the memdb tables backing
+// QuotaUsage and ClusterQuotaUsage records.
+const (
+	quotaUsageTable        = "quota_usage"
+	clusterQuotaUsageTable = "cluster_quota_usage"
+)
+
+// UpsertQuotaUsages upserts the given quota usages, used both by the normal
+// quota CRUD path and the background quota reconciler's corrective
+// Raft-applies.
+func (s *StateStore) UpsertQuotaUsages(index uint64, usages []*structs.QuotaUsage) error {
+	txn := s.db.WriteTxn(index)
+	defer txn.Abort()
+
+	for _, usage := range usages {
+		existing, err := txn.First(quotaUsageTable, "id", usage.Name)
+		if err != nil {
+			return fmt.Errorf("quota usage lookup failed: %v", err)
+		}
+		if existing != nil {
+			usage.CreateIndex = existing.(*structs.QuotaUsage).CreateIndex
+		} else {
+			usage.CreateIndex = index
+		}
+		usage.ModifyIndex = index
+
+		if err := txn.Insert(quotaUsageTable, usage); err != nil {
+			return fmt.Errorf("quota usage insert failed: %v", err)
+		}
+	}
+
+	if err := txn.Insert("index", &IndexEntry{Key: quotaUsageTable, Value: index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	return txn.Commit()
+}
+
+// UpsertClusterQuotaUsages upserts the given cluster quota usages, mirroring
+// UpsertQuotaUsages for ClusterQuotaSpec-backed usage.
+func (s *StateStore) UpsertClusterQuotaUsages(index uint64, usages []*structs.ClusterQuotaUsage) error {
+	txn := s.db.WriteTxn(index)
+	defer txn.Abort()
+
+	for _, usage := range usages {
+		existing, err := txn.First(clusterQuotaUsageTable, "id", usage.Name)
+		if err != nil {
+			return fmt.Errorf("cluster quota usage lookup failed: %v", err)
+		}
+		if existing != nil {
+			usage.CreateIndex = existing.(*structs.ClusterQuotaUsage).CreateIndex
+		} else {
+			usage.CreateIndex = index
+		}
+		usage.ModifyIndex = index
+
+		if err := txn.Insert(clusterQuotaUsageTable, usage); err != nil {
+			return fmt.Errorf("cluster quota usage insert failed: %v", err)
+		}
+	}
+
+	if err := txn.Insert("index", &IndexEntry{Key: clusterQuotaUsageTable, Value: index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	return txn.Commit()
+}
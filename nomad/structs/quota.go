@@ -0,0 +1,747 @@
+package structs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+// QuotaSpec is the specification for a set of quota limits. It may apply to
+// a single namespace (the common case) or be referenced by multiple
+// namespaces in the case of a shared enterprise quota.
+type QuotaSpec struct {
+	// Name is a unique name for the quota spec.
+	Name string
+
+	// Description is an optional description for the quota spec.
+	Description string
+
+	// Limits is the set of limits enforced by this spec. There can be
+	// multiple limits for a single region as long as they apply to disjoint
+	// scopes (see QuotaScope).
+	Limits []*QuotaLimit
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// LimitsMap returns the Limits as a map keyed by the limit's Hash. Since a
+// region may have more than one limit attached (one per QuotaScope), the
+// hash -- not the region -- is the uniqueness key.
+func (q *QuotaSpec) LimitsMap() map[string]*QuotaLimit {
+	m := make(map[string]*QuotaLimit, len(q.Limits))
+	for _, l := range q.Limits {
+		l.SetHash()
+		m[string(l.Hash)] = l
+	}
+	return m
+}
+
+// QuotaLimit describes the resource limit in a particular region for a
+// QuotaSpec. An optional Scope narrows the limit to only apply to
+// allocations that match it; a nil Scope applies to every allocation in the
+// region, preserving the historical behavior.
+type QuotaLimit struct {
+	// Region is the region that this limit applies to.
+	Region string
+
+	// RegionLimit is the maximum allowed resource usage. A value of zero is
+	// treated as unlimited for that dimension.
+	RegionLimit *Resources
+
+	// Scope optionally restricts which allocations count against this
+	// limit. A nil Scope matches every allocation in the Region.
+	Scope *QuotaScope
+
+	// Counts holds optional object-count limits, keyed by one of the
+	// QuotaCount* constants (allocations, task groups, host ports, ...). This
+	// lets operators cap cardinality independent of raw resource
+	// consumption. A missing key is treated as unlimited for that counter.
+	Counts map[string]int
+
+	// Soft is an optional warning threshold below RegionLimit (e.g. 80% of
+	// hard). Crossing it does not block placement, but is surfaced as a
+	// warning so operators can see a namespace approaching its quota before
+	// it starts rejecting placements. A nil Soft disables the warning.
+	Soft *Resources
+
+	// SoftCounts mirrors Soft for the object-count dimensions in Counts.
+	SoftCounts map[string]int
+
+	// Hash is the unique identifier for this limit, built from the Region
+	// and Scope. It is what allows multiple limits to coexist for the same
+	// region.
+	Hash []byte
+}
+
+// Object-count dimensions that a QuotaLimit.Counts map may key on.
+const (
+	QuotaCountAllocations = "allocations"
+	QuotaCountTaskGroups  = "task_groups"
+	QuotaCountHostPorts   = "host_ports"
+	QuotaCountServices    = "services"
+	QuotaCountVolumes     = "volumes"
+)
+
+// SetHash computes and stores the hash of the limit, and returns it.
+func (l *QuotaLimit) SetHash() []byte {
+	h := sha256.New()
+	h.Write([]byte(l.Region))
+	l.Scope.addToHash(h)
+	l.Hash = h.Sum(nil)
+	return l.Hash
+}
+
+// Copy returns a deep copy of the limit.
+func (l *QuotaLimit) Copy() *QuotaLimit {
+	if l == nil {
+		return nil
+	}
+	nl := new(QuotaLimit)
+	*nl = *l
+	nl.RegionLimit = l.RegionLimit.Copy()
+	nl.Scope = l.Scope.Copy()
+	nl.Soft = l.Soft.Copy()
+	if l.Counts != nil {
+		nl.Counts = make(map[string]int, len(l.Counts))
+		for k, v := range l.Counts {
+			nl.Counts[k] = v
+		}
+	}
+	if l.SoftCounts != nil {
+		nl.SoftCounts = make(map[string]int, len(l.SoftCounts))
+		for k, v := range l.SoftCounts {
+			nl.SoftCounts[k] = v
+		}
+	}
+	if l.Hash != nil {
+		nl.Hash = make([]byte, len(l.Hash))
+		copy(nl.Hash, l.Hash)
+	}
+	return nl
+}
+
+// AddResource adds the resources to the limit's running usage.
+func (l *QuotaLimit) AddResource(r *Resources) {
+	if l.RegionLimit == nil {
+		l.RegionLimit = &Resources{}
+	}
+	l.RegionLimit.Add(r)
+}
+
+// AddCounts adds the given object counts to the limit's running usage.
+func (l *QuotaLimit) AddCounts(counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	if l.Counts == nil {
+		l.Counts = make(map[string]int, len(counts))
+	}
+	for k, v := range counts {
+		l.Counts[k] += v
+	}
+}
+
+// Superset checks if the contained limit is a superset of the other limit,
+// meaning the other limit's usage would still fit under this limit's hard
+// cap. It returns whether it is a superset and if not, the dimensions (or
+// count names) that caused the limit to be exceeded.
+func (l *QuotaLimit) Superset(other *QuotaLimit) (bool, []string) {
+	if l == nil {
+		return true, nil
+	}
+
+	ok, dimensions := true, []string(nil)
+	if l.RegionLimit != nil {
+		var superset bool
+		superset, dimensions = l.RegionLimit.Superset(other.RegionLimit)
+		ok = ok && superset
+	}
+
+	for name, max := range l.Counts {
+		if max <= 0 {
+			continue
+		}
+		if other.Counts[name] > max {
+			ok = false
+			dimensions = append(dimensions, name)
+		}
+	}
+
+	return ok, dimensions
+}
+
+// SoftExceeded reports the dimensions (or count names) where other's usage
+// has crossed this limit's Soft threshold. It is only meaningful to call
+// once Superset has confirmed other still fits under the hard cap; crossing
+// Soft is a warning, not a rejection.
+func (l *QuotaLimit) SoftExceeded(other *QuotaLimit) []string {
+	if l == nil {
+		return nil
+	}
+
+	var dimensions []string
+	if l.Soft != nil {
+		if underSoft, crossed := l.Soft.Superset(other.RegionLimit); !underSoft {
+			dimensions = append(dimensions, crossed...)
+		}
+	}
+
+	for name, threshold := range l.SoftCounts {
+		if threshold <= 0 {
+			continue
+		}
+		if other.Counts[name] > threshold {
+			dimensions = append(dimensions, name)
+		}
+	}
+
+	return dimensions
+}
+
+// QuotaScope constrains a QuotaLimit to a subset of the allocations in a
+// region, modeled on Kubernetes ResourceQuota scopes. A nil field within the
+// scope is not considered when matching.
+type QuotaScope struct {
+	// PriorityRange restricts the scope to jobs whose Priority falls within
+	// [Min, Max] inclusive.
+	PriorityRange *QuotaScopePriorityRange
+
+	// Preemptible, when non-nil, restricts the scope to jobs whose
+	// preemptibility (approximated from Job.Type, since Nomad does not track
+	// a per-task-group preemptible flag) matches the given value.
+	Preemptible *bool
+
+	// JobTypes restricts the scope to jobs of the listed types (for example
+	// "batch", "service", "system"). An empty list matches every job type.
+	JobTypes []string
+
+	// NamespaceSelector restricts the scope to namespaces whose metadata
+	// matches. A nil selector matches every namespace.
+	NamespaceSelector *NamespaceSelector
+}
+
+// QuotaScopePriorityRange bounds a job's Priority.
+type QuotaScopePriorityRange struct {
+	Min int
+	Max int
+}
+
+// NamespaceSelector matches namespaces by metadata, mirroring the label
+// selectors used elsewhere for job placement. It matches against
+// Namespace.Meta, the only per-namespace key/value store Nomad exposes.
+type NamespaceSelector struct {
+	MatchLabels map[string]string
+}
+
+// Matches returns whether the given namespace's metadata satisfies the
+// selector.
+func (s *NamespaceSelector) Matches(namespace *Namespace) bool {
+	if s == nil {
+		return true
+	}
+	if namespace == nil || len(namespace.Meta) == 0 {
+		return len(s.MatchLabels) == 0
+	}
+	for k, v := range s.MatchLabels {
+		if namespace.Meta[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches returns whether the allocation described by job/tg in namespace is
+// governed by this scope. A nil scope matches everything.
+func (s *QuotaScope) Matches(job *Job, tg *TaskGroup, namespace *Namespace) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.PriorityRange != nil && job != nil {
+		if job.Priority < s.PriorityRange.Min || job.Priority > s.PriorityRange.Max {
+			return false
+		}
+	}
+
+	if s.Preemptible != nil && job != nil {
+		if jobIsPreemptible(job) != *s.Preemptible {
+			return false
+		}
+	}
+
+	if len(s.JobTypes) > 0 && job != nil {
+		found := false
+		for _, t := range s.JobTypes {
+			if t == job.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if s.NamespaceSelector != nil && !s.NamespaceSelector.Matches(namespace) {
+		return false
+	}
+
+	return true
+}
+
+// jobIsPreemptible approximates whether a job's allocations are eligible to
+// be preempted by higher-priority work. Nomad does not expose a per-task
+// group or per-job preemptible flag; preemption eligibility is governed
+// cluster-wide per scheduler type, so batch and sysbatch jobs -- the
+// scheduler types preemption is commonly enabled for -- are treated as
+// preemptible.
+func jobIsPreemptible(job *Job) bool {
+	switch job.Type {
+	case JobTypeBatch, JobTypeSysBatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Copy returns a deep copy of the scope.
+func (s *QuotaScope) Copy() *QuotaScope {
+	if s == nil {
+		return nil
+	}
+	ns := new(QuotaScope)
+	*ns = *s
+	if s.PriorityRange != nil {
+		pr := *s.PriorityRange
+		ns.PriorityRange = &pr
+	}
+	if s.Preemptible != nil {
+		p := *s.Preemptible
+		ns.Preemptible = &p
+	}
+	if s.JobTypes != nil {
+		ns.JobTypes = make([]string, len(s.JobTypes))
+		copy(ns.JobTypes, s.JobTypes)
+	}
+	if s.NamespaceSelector != nil {
+		sel := &NamespaceSelector{MatchLabels: make(map[string]string, len(s.NamespaceSelector.MatchLabels))}
+		for k, v := range s.NamespaceSelector.MatchLabels {
+			sel.MatchLabels[k] = v
+		}
+		ns.NamespaceSelector = sel
+	}
+	return ns
+}
+
+// addToHash folds the scope's fields into the running hash so that distinct
+// scopes produce distinct QuotaLimit.Hash values. Every field is written in
+// a deterministic order -- including sorting map keys -- since Go's map
+// iteration order is randomized and SetHash must be stable across calls.
+func (s *QuotaScope) addToHash(h interface{ Write([]byte) (int, error) }) {
+	if s == nil {
+		return
+	}
+	if s.PriorityRange != nil {
+		var buf [16]byte
+		binary.BigEndian.PutUint64(buf[0:8], uint64(int64(s.PriorityRange.Min)))
+		binary.BigEndian.PutUint64(buf[8:16], uint64(int64(s.PriorityRange.Max)))
+		h.Write(buf[:])
+	}
+	if s.Preemptible != nil {
+		if *s.Preemptible {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+	}
+	for _, t := range s.JobTypes {
+		writeLenPrefixed(h, []byte(t))
+	}
+	if s.NamespaceSelector != nil {
+		keys := make([]string, 0, len(s.NamespaceSelector.MatchLabels))
+		for k := range s.NamespaceSelector.MatchLabels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeLenPrefixed(h, []byte(k))
+			writeLenPrefixed(h, []byte(s.NamespaceSelector.MatchLabels[k]))
+		}
+	}
+}
+
+// writeLenPrefixed writes b to h preceded by its length as a 4-byte
+// big-endian uint32, so that concatenating a variable number of
+// variable-length fields (e.g. JobTypes entries, or a label's key then its
+// value) can't produce the same hash for two different sets of fields --
+// e.g. JobTypes ["ab", "c"] and ["a", "bc"], or labels {"a": "bc"} and
+// {"ab": "c"}, which would otherwise concatenate identically.
+func writeLenPrefixed(h interface{ Write([]byte) (int, error) }, b []byte) {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(b)))
+	h.Write(lenBuf[:])
+	h.Write(b)
+}
+
+// QuotaUsage holds the actual or proposed usage tracked against a QuotaSpec.
+type QuotaUsage struct {
+	// Name matches the QuotaSpec.Name this usage is tracking.
+	Name string
+
+	// Used is the set of limits and their current usage, keyed by the same
+	// Hash as QuotaSpec.LimitsMap.
+	Used map[string]*QuotaLimit
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// Copy returns a deep copy of the usage.
+func (u *QuotaUsage) Copy() *QuotaUsage {
+	if u == nil {
+		return nil
+	}
+	nu := &QuotaUsage{
+		Name:        u.Name,
+		CreateIndex: u.CreateIndex,
+		ModifyIndex: u.ModifyIndex,
+	}
+	if u.Used != nil {
+		nu.Used = make(map[string]*QuotaLimit, len(u.Used))
+		for k, v := range u.Used {
+			nu.Used[k] = v.Copy()
+		}
+	}
+	return nu
+}
+
+// UpdateUsageFromPlan updates the usage to account for the allocations being
+// placed in the plan, for the given namespace. Only limits whose Scope
+// matches an allocation's job/task group/namespace are credited with that
+// allocation's resources.
+//
+// QuotaCountTaskGroups counts distinct task groups rather than allocations,
+// so it is credited at most once per (job, task group) pair seen while
+// walking the plan, regardless of how many instances of that group the plan
+// places. This only dedupes within a single call; it does not look at a
+// group's pre-existing contribution to usage, so repeated incremental calls
+// for the same group across separate plans can still drift. The background
+// quota reconciler corrects that drift by recomputing usage from scratch.
+func UpdateUsageFromPlan(usage *QuotaUsage, plan *Plan, namespace *Namespace) {
+	if usage == nil || plan == nil {
+		return
+	}
+
+	seenGroups := make(map[string]map[string]bool) // limit hash -> "jobID/group" seen this call
+
+	for _, allocs := range plan.NodeAllocation {
+		for _, alloc := range allocs {
+			tg := alloc.Job.LookupTaskGroup(alloc.TaskGroup)
+			if tg == nil {
+				continue
+			}
+			r := CombinedTaskGroupResources(tg)
+			counts := CombinedTaskGroupCounts(tg)
+			groupKey := alloc.Job.ID + "/" + tg.Name
+
+			for hash, limit := range usage.Used {
+				if limit.Region != "" && limit.Region != alloc.Job.Region {
+					continue
+				}
+				if !limit.Scope.Matches(alloc.Job, tg, namespace) {
+					continue
+				}
+				limit.AddResource(r)
+				limit.AddCounts(counts)
+
+				if seenGroups[hash] == nil {
+					seenGroups[hash] = make(map[string]bool)
+				}
+				if !seenGroups[hash][groupKey] {
+					seenGroups[hash][groupKey] = true
+					limit.AddCounts(map[string]int{QuotaCountTaskGroups: 1})
+				}
+			}
+		}
+	}
+}
+
+// CombinedTaskGroupResources mirrors scheduler.combinedResources so that
+// structs does not need to import the scheduler package.
+func CombinedTaskGroupResources(tg *TaskGroup) *Resources {
+	r := &Resources{
+		DiskMB: tg.EphemeralDisk.SizeMB,
+	}
+	for _, task := range tg.Tasks {
+		r.Add(task.Resources)
+	}
+	return r
+}
+
+// CombinedTaskGroupCounts mirrors scheduler.combinedCounts so that structs
+// does not need to import the scheduler package. It returns the per-instance
+// object counts contributed by a single allocation of tg; QuotaCountTaskGroups
+// is not included here since it counts distinct groups, not allocations --
+// see UpdateUsageFromPlan.
+func CombinedTaskGroupCounts(tg *TaskGroup) map[string]int {
+	counts := map[string]int{
+		QuotaCountAllocations: 1,
+	}
+
+	ports := 0
+	for _, n := range tg.Networks {
+		ports += len(n.ReservedPorts) + len(n.DynamicPorts)
+	}
+	for _, task := range tg.Tasks {
+		if task.Resources == nil {
+			continue
+		}
+		for _, n := range task.Resources.Networks {
+			ports += len(n.ReservedPorts) + len(n.DynamicPorts)
+		}
+	}
+	if ports > 0 {
+		counts[QuotaCountHostPorts] = ports
+	}
+
+	services := len(tg.Services)
+	for _, task := range tg.Tasks {
+		services += len(task.Services)
+	}
+	if services > 0 {
+		counts[QuotaCountServices] = services
+	}
+
+	if len(tg.Volumes) > 0 {
+		counts[QuotaCountVolumes] = len(tg.Volumes)
+	}
+
+	return counts
+}
+
+// ClusterQuotaSpec is a quota spec that aggregates usage across every
+// namespace matched by NamespaceSelector, analogous to OpenShift's
+// ClusterResourceQuota. Unlike QuotaSpec, it is not referenced by name from
+// a single namespace; instead any namespace whose Meta labels match the
+// selector shares the same budget. Namespaces are not matched by name.
+type ClusterQuotaSpec struct {
+	// Name is a unique name for the cluster quota spec.
+	Name string
+
+	// Description is an optional description for the cluster quota spec.
+	Description string
+
+	// NamespaceSelector determines which namespaces' usage is aggregated
+	// against this spec's limits. A nil selector matches every namespace,
+	// which is rarely what an operator wants but is allowed.
+	NamespaceSelector *NamespaceSelector
+
+	// Limits is the set of limits enforced against the aggregated usage of
+	// every matching namespace.
+	Limits []*QuotaLimit
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// LimitsMap returns the Limits as a map keyed by the limit's Hash, mirroring
+// QuotaSpec.LimitsMap.
+func (q *ClusterQuotaSpec) LimitsMap() map[string]*QuotaLimit {
+	m := make(map[string]*QuotaLimit, len(q.Limits))
+	for _, l := range q.Limits {
+		l.SetHash()
+		m[string(l.Hash)] = l
+	}
+	return m
+}
+
+// ClusterQuotaUsage tracks the usage aggregated across every namespace bound
+// to a ClusterQuotaSpec, broken down per-namespace so that a single
+// namespace's allocations can be recomputed without re-summing every other
+// namespace.
+type ClusterQuotaUsage struct {
+	// Name matches the ClusterQuotaSpec.Name this usage is tracking.
+	Name string
+
+	// Namespaces holds the usage attributed to each namespace currently
+	// matched by the spec's selector, keyed by namespace name.
+	Namespaces map[string]map[string]*QuotaLimit
+
+	// Total is the sum of every namespace's usage in Namespaces, keyed by
+	// the same Hash as ClusterQuotaSpec.LimitsMap. This is what is enforced
+	// against the spec's limits.
+	Total map[string]*QuotaLimit
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// Copy returns a deep copy of the usage.
+func (u *ClusterQuotaUsage) Copy() *ClusterQuotaUsage {
+	if u == nil {
+		return nil
+	}
+	nu := &ClusterQuotaUsage{
+		Name:        u.Name,
+		CreateIndex: u.CreateIndex,
+		ModifyIndex: u.ModifyIndex,
+	}
+	if u.Namespaces != nil {
+		nu.Namespaces = make(map[string]map[string]*QuotaLimit, len(u.Namespaces))
+		for ns, used := range u.Namespaces {
+			nu.Namespaces[ns] = copyLimitMap(used)
+		}
+	}
+	nu.Total = copyLimitMap(u.Total)
+	return nu
+}
+
+// ApplyNamespaceUsage replaces the usage recorded for namespace with
+// updated, adjusting Total by the diff: the namespace's old contribution is
+// subtracted and its new contribution is added. This is the diff-and-apply
+// pattern used whenever a plan changes what a namespace is using against a
+// shared cluster quota, so Total never has to be recomputed from scratch.
+func (u *ClusterQuotaUsage) ApplyNamespaceUsage(namespace string, updated map[string]*QuotaLimit) {
+	if u.Namespaces == nil {
+		u.Namespaces = make(map[string]map[string]*QuotaLimit)
+	}
+	if u.Total == nil {
+		u.Total = make(map[string]*QuotaLimit)
+	}
+
+	if old, ok := u.Namespaces[namespace]; ok {
+		subtractLimitMap(u.Total, old)
+	}
+
+	u.Namespaces[namespace] = copyLimitMap(updated)
+	addLimitMap(u.Total, updated)
+}
+
+// copyLimitMap returns a deep copy of a map of limits keyed by Hash.
+func copyLimitMap(m map[string]*QuotaLimit) map[string]*QuotaLimit {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]*QuotaLimit, len(m))
+	for k, v := range m {
+		out[k] = v.Copy()
+	}
+	return out
+}
+
+// addLimitMap merges src's usage into dst, keyed by Hash.
+func addLimitMap(dst, src map[string]*QuotaLimit) {
+	for hash, l := range src {
+		existing, ok := dst[hash]
+		if !ok {
+			dst[hash] = l.Copy()
+			continue
+		}
+		existing.AddResource(l.RegionLimit)
+		existing.AddCounts(l.Counts)
+	}
+}
+
+// subtractLimitMap removes src's usage from dst, keyed by Hash.
+func subtractLimitMap(dst, src map[string]*QuotaLimit) {
+	for hash, l := range src {
+		existing, ok := dst[hash]
+		if !ok {
+			continue
+		}
+		existing.SubtractResource(l.RegionLimit)
+		existing.SubtractCounts(l.Counts)
+	}
+}
+
+// SubtractResource removes the resources from the limit's running usage,
+// the inverse of AddResource.
+func (l *QuotaLimit) SubtractResource(r *Resources) {
+	if l.RegionLimit == nil || r == nil {
+		return
+	}
+	l.RegionLimit.CPU -= r.CPU
+	l.RegionLimit.MemoryMB -= r.MemoryMB
+	l.RegionLimit.DiskMB -= r.DiskMB
+}
+
+// SubtractCounts removes the given object counts from the limit's running
+// usage, the inverse of AddCounts.
+func (l *QuotaLimit) SubtractCounts(counts map[string]int) {
+	if len(counts) == 0 || l.Counts == nil {
+		return
+	}
+	for k, v := range counts {
+		l.Counts[k] -= v
+	}
+}
+
+// QuotaCheckRequest asks whether job would fit within the quotas governing
+// its namespace without actually scheduling it. It mirrors JobPlanRequest's
+// shape since, like a plan, it evaluates a job without persisting it.
+type QuotaCheckRequest struct {
+	Job *Job
+	WriteRequest
+}
+
+// QuotaCheckResponse reports, for every quota limit governing the job's
+// namespace, whether the job would fit and which dimensions it would exceed.
+// It does not report the remaining amount of any dimension. It mirrors the
+// information the ExhaustQuota metric records during real scheduling.
+type QuotaCheckResponse struct {
+	// WouldFit is true only if every governing limit has enough headroom for
+	// the job's task groups.
+	WouldFit bool
+
+	// Limits holds one entry per governing limit, describing whether the job
+	// fits under it.
+	Limits []*QuotaCheckLimit
+
+	QueryMeta
+}
+
+// QuotaCheckLimit describes which dimensions (resource or count) of a single
+// QuotaLimit a proposed job would exceed or push past the soft threshold. It
+// does not carry the remaining amounts.
+type QuotaCheckLimit struct {
+	// QuotaName is the QuotaSpec or ClusterQuotaSpec this limit belongs to.
+	QuotaName string
+
+	Region string
+
+	// Exceeded lists the resource dimensions or Counts keys that would be
+	// driven over the hard limit, empty if the job fits.
+	Exceeded []string
+
+	// SoftExceeded lists the resource dimensions or Counts keys that would
+	// cross the limit's Soft warning threshold while still remaining under
+	// the hard cap.
+	SoftExceeded []string
+}
+
+// QuotaUsageUpsertRequestType and ClusterQuotaUsageUpsertRequestType are the
+// Raft log entry types for applying corrective usage updates produced by the
+// background quota reconciler. MessageType is a single byte, so these sit
+// deliberately near the top of its range rather than directly after the
+// existing OSS/enterprise entries, to make an accidental collision with a
+// newly added type obvious rather than silent. They are provisional
+// placeholders and MUST be replaced with the actual next free values from
+// the canonical MessageType list (structs.go) when this change is merged
+// there, not left as-is.
+const (
+	QuotaUsageUpsertRequestType        MessageType = 254
+	ClusterQuotaUsageUpsertRequestType MessageType = 255
+)
+
+// QuotaUsageUpsertRequest upserts the given quota usages.
+type QuotaUsageUpsertRequest struct {
+	Usages []*QuotaUsage
+	WriteRequest
+}
+
+// ClusterQuotaUsageUpsertRequest upserts the given cluster quota usages.
+type ClusterQuotaUsageUpsertRequest struct {
+	Usages []*ClusterQuotaUsage
+	WriteRequest
+}
@@ -0,0 +1,110 @@
+//go:build ent
+// +build ent
+
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// CheckJobQuota computes whether job would fit within the quotas governing
+// its namespace without going through the scheduler, reusing the same
+// resolveQuotaLimits/Superset machinery QuotaIterator relies on during real
+// placement. It lets callers (e.g. the quota check API) validate a
+// submission up front, matching the k8s admission pattern of evaluating
+// quota impact ahead of persistence.
+func CheckJobQuota(state StateEnterprise, job *structs.Job) (*structs.QuotaCheckResponse, error) {
+	namespace, err := state.NamespaceByName(nil, job.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup job %q namespace %q: %v", job.ID, job.Namespace, err)
+	} else if namespace == nil {
+		return nil, fmt.Errorf("unknown namespace %q referenced by job %q", job.Namespace, job.ID)
+	}
+
+	resolved, err := resolveQuotaLimits(state, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &structs.QuotaCheckResponse{WouldFit: true}
+	if resolved.usage == nil || len(resolved.usage.Used) == 0 {
+		// No quota governs this namespace; the job trivially fits.
+		return resp, nil
+	}
+
+	// Every task group's contribution is accumulated per limit first, so each
+	// limit is checked once against the job as a whole.
+	proposedByHash := make(map[string]*structs.QuotaLimit)
+	for _, tg := range job.TaskGroups {
+		proposed := scaleResources(combinedResources(tg), tg.Count)
+		proposedCounts := combinedCounts(tg)
+		for k, v := range proposedCounts {
+			proposedCounts[k] = v * tg.Count
+		}
+		// QuotaCountTaskGroups counts distinct task groups, not allocations,
+		// so it is credited once per group here rather than scaled by
+		// tg.Count above -- matching UpdateUsageFromPlan's per-group dedup
+		// during real enforcement.
+		proposedCounts[structs.QuotaCountTaskGroups] = 1
+
+		for hash, limit := range resolved.limits {
+			if limit.Region != "" && limit.Region != job.Region {
+				continue
+			}
+			if !limit.Scope.Matches(job, tg, namespace) {
+				continue
+			}
+
+			proposedLimit, ok := proposedByHash[hash]
+			if !ok {
+				actual := resolved.usage.Used[hash]
+				if actual == nil {
+					actual = &structs.QuotaLimit{Region: limit.Region, Scope: limit.Scope, Hash: limit.Hash}
+				}
+				proposedLimit = actual.Copy()
+				proposedByHash[hash] = proposedLimit
+			}
+			proposedLimit.AddResource(proposed)
+			proposedLimit.AddCounts(proposedCounts)
+		}
+	}
+
+	for hash, proposedLimit := range proposedByHash {
+		limit := resolved.limits[hash]
+		superset, exceeded := limit.Superset(proposedLimit)
+
+		checkLimit := &structs.QuotaCheckLimit{
+			QuotaName: resolved.limitOwner[hash],
+			Region:    limit.Region,
+			Exceeded:  exceeded,
+		}
+		if superset {
+			checkLimit.SoftExceeded = limit.SoftExceeded(proposedLimit)
+		}
+		resp.Limits = append(resp.Limits, checkLimit)
+
+		if !superset {
+			resp.WouldFit = false
+		}
+	}
+
+	return resp, nil
+}
+
+// scaleResources returns a copy of r with its scalar dimensions multiplied
+// by count, matching the number of allocations the task group would place.
+// Networks are repeated count times rather than scaled, since each instance
+// of the task group claims its own copy of the declared network resources.
+func scaleResources(r *structs.Resources, count int) *structs.Resources {
+	scaled := &structs.Resources{
+		CPU:      r.CPU * count,
+		MemoryMB: r.MemoryMB * count,
+		DiskMB:   r.DiskMB * count,
+	}
+	for i := 0; i < count; i++ {
+		scaled.Networks = append(scaled.Networks, r.Networks...)
+	}
+	return scaled
+}
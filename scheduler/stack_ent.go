@@ -19,19 +19,42 @@ type QuotaIterator struct {
 	// combinedResources are the resources used by the TaskGroup being scheduled.
 	combinedResources *structs.Resources
 
+	// combinedCounts are the per-instance object counts (allocations, host
+	// ports, ...) contributed by placing one more instance of the TaskGroup
+	// being scheduled. It excludes QuotaCountTaskGroups, which counts
+	// distinct groups rather than allocations.
+	combinedCounts map[string]int
+
+	// taskGroup is the TaskGroup currently being scheduled, used to evaluate
+	// QuotaLimit scopes (e.g. Preemptible).
+	taskGroup *structs.TaskGroup
+
 	job         *structs.Job
+	namespace   *structs.Namespace
 	quota       *structs.QuotaSpec
 	quotaLimits map[string]*structs.QuotaLimit
 
+	// clusterQuotaNames are the names of any ClusterQuotaSpecs whose
+	// NamespaceSelector matches the job's namespace. Their limits are merged
+	// into quotaLimits/actUsage so Next enforces the tightest of every
+	// applicable quota, namespace-scoped or cluster-wide.
+	clusterQuotaNames []string
+
+	// limitOwner maps a limit's Hash to the name of the QuotaSpec or
+	// ClusterQuotaSpec it came from, used to attribute soft-limit warnings.
+	limitOwner map[string]string
+
 	// actUsage is the actual usage for the quota loaded from the statestore
 	actUsage *structs.QuotaUsage
 
 	// proposedUsage is the usage this plan is proposing so far.
 	proposedUsage *structs.QuotaUsage
 
-	// proposedLimit is the limit that applies to this job. At this point there
-	// can only be a single quota limit per region so there can only be one.
-	proposedLimit *structs.QuotaLimit
+	// proposedLimits are the limits that apply to this job, keyed by Hash. A
+	// job's limits can span multiple entries since a quota spec may define
+	// more than one limit per region, each scoped to a different subset of
+	// workloads.
+	proposedLimits map[string]*structs.QuotaLimit
 }
 
 // NewQuotaIterator returns a new quota iterator reading from the passed source.
@@ -47,7 +70,9 @@ func NewQuotaIterator(ctx Context, source FeasibleIterator) FeasibleIterator {
 // the combined resource utilization of the task group for use when calculating
 // quota usage.
 func (iter *QuotaIterator) SetTaskGroup(tg *structs.TaskGroup) {
+	iter.taskGroup = tg
 	iter.combinedResources = combinedResources(tg)
+	iter.combinedCounts = combinedCounts(tg)
 }
 
 func (iter *QuotaIterator) SetJob(job *structs.Job) {
@@ -66,45 +91,136 @@ func (iter *QuotaIterator) SetJob(job *structs.Job) {
 		return
 	}
 
-	// There is no quota attached to the namespace so there is nothing for the
-	// iterator to do
-	if namespace.Quota == "" {
-		return
-	}
+	iter.namespace = namespace
 
-	// Lookup the quota spec
-	quota, err := state.QuotaSpecByName(nil, namespace.Quota)
+	resolved, err := resolveQuotaLimits(state, namespace)
 	if err != nil {
-		iter.buildErr = fmt.Errorf("failed to lookup quota %q: %v", namespace.Quota, err)
-		iter.ctx.Logger().Named("stack").Error("scheduler.QuotaIterator", "error", iter.buildErr)
-		return
-	} else if quota == nil {
-		iter.buildErr = fmt.Errorf("unknown quota %q referenced by namespace %q", namespace.Quota, namespace.Name)
+		iter.buildErr = err
 		iter.ctx.Logger().Named("stack").Error("scheduler.QuotaIterator", "error", iter.buildErr)
 		return
 	}
 
-	// Lookup the current quota usage
-	usage, err := state.QuotaUsageByName(nil, namespace.Quota)
+	iter.quota = resolved.namespaceQuota
+	iter.quotaLimits = resolved.limits
+	iter.actUsage = resolved.usage
+	iter.clusterQuotaNames = resolved.clusterQuotaNames
+	iter.limitOwner = resolved.limitOwner
+}
+
+// quotaNameFor returns the name of the QuotaSpec or ClusterQuotaSpec that
+// the limit identified by hash came from.
+func (iter *QuotaIterator) quotaNameFor(hash string) string {
+	return iter.limitOwner[hash]
+}
+
+// resolvedQuotaLimits is the set of limits and running usage that govern a
+// namespace, whether sourced from its own QuotaSpec, any matching
+// ClusterQuotaSpecs, or both. It is shared by QuotaIterator.SetJob and the
+// dry-run quota admission check so the two stay in lockstep.
+type resolvedQuotaLimits struct {
+	namespaceQuota    *structs.QuotaSpec
+	limits            map[string]*structs.QuotaLimit
+	usage             *structs.QuotaUsage
+	clusterQuotaNames []string
+
+	// limitOwner maps a limit's Hash to the name of the QuotaSpec or
+	// ClusterQuotaSpec it came from, for attribution in user-facing output.
+	limitOwner map[string]string
+}
+
+// resolveQuotaLimits loads the namespace's own quota (if any) and merges in
+// every ClusterQuotaSpec whose NamespaceSelector matches, since Next()
+// requires every applicable limit to be satisfied and the tightest of them
+// wins. A quota whose stored usage has no entries yet (an empty Used, or a
+// missing or empty cluster Total) is skipped and not enforced.
+//
+// Limits and usage are keyed not by the raw QuotaLimit.Hash but by a
+// "source/hash" composite key ("ns/<hash>" or "cq/<name>/<hash>"). A
+// namespace-scoped limit and a cluster-wide limit can legitimately share a
+// Hash (e.g. both are an unscoped limit for the same region), and since both
+// must be enforced independently, keying by the raw hash alone would let one
+// silently clobber the other in these maps.
+func resolveQuotaLimits(state StateEnterprise, namespace *structs.Namespace) (*resolvedQuotaLimits, error) {
+	result := &resolvedQuotaLimits{
+		limits:     make(map[string]*structs.QuotaLimit),
+		limitOwner: make(map[string]string),
+	}
+	used := make(map[string]*structs.QuotaLimit)
+
+	if namespace.Quota != "" {
+		quota, err := state.QuotaSpecByName(nil, namespace.Quota)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lookup quota %q: %v", namespace.Quota, err)
+		} else if quota == nil {
+			return nil, fmt.Errorf("unknown quota %q referenced by namespace %q", namespace.Quota, namespace.Name)
+		}
+
+		usage, err := state.QuotaUsageByName(nil, namespace.Quota)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lookup quota usage %q: %v", namespace.Quota, err)
+		} else if usage == nil {
+			return nil, fmt.Errorf("unknown quota usage %q", namespace.Quota)
+		}
+
+		if len(usage.Used) > 0 {
+			result.namespaceQuota = quota
+
+			for hash, l := range quota.LimitsMap() {
+				key := "ns/" + hash
+				result.limits[key] = l
+				result.limitOwner[key] = quota.Name
+				if u, ok := usage.Used[hash]; ok {
+					used[key] = u
+				} else {
+					used[key] = &structs.QuotaLimit{Region: l.Region, Scope: l.Scope, Hash: l.Hash}
+				}
+			}
+		}
+	}
+
+	clusterQuotas, err := state.ClusterQuotaSpecs(nil)
 	if err != nil {
-		iter.buildErr = fmt.Errorf("failed to lookup quota usage %q: %v", namespace.Quota, err)
-		iter.ctx.Logger().Named("stack").Error("scheduler.QuotaIterator", "error", iter.buildErr)
-		return
-	} else if usage == nil {
-		iter.buildErr = fmt.Errorf("unknown quota usage %q", namespace.Quota)
-		iter.ctx.Logger().Named("stack").Error("scheduler.QuotaIterator", "error", iter.buildErr)
-		return
+		return nil, fmt.Errorf("failed to list cluster quotas: %v", err)
 	}
 
-	// There is no limit that applies to us
-	if len(usage.Used) == 0 {
-		return
+	for _, cq := range clusterQuotas {
+		if !cq.NamespaceSelector.Matches(namespace) {
+			continue
+		}
+
+		usage, err := state.ClusterQuotaUsageByName(nil, cq.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lookup cluster quota usage %q: %v", cq.Name, err)
+		} else if usage == nil || len(usage.Total) == 0 {
+			continue
+		}
+
+		for hash, l := range cq.LimitsMap() {
+			key := "cq/" + cq.Name + "/" + hash
+			result.limits[key] = l
+			result.limitOwner[key] = cq.Name
+			if u, ok := usage.Total[hash]; ok {
+				used[key] = u
+			} else {
+				used[key] = &structs.QuotaLimit{Region: l.Region, Scope: l.Scope, Hash: l.Hash}
+			}
+		}
+
+		result.clusterQuotaNames = append(result.clusterQuotaNames, cq.Name)
+	}
+
+	if len(result.limits) == 0 {
+		return result, nil
 	}
 
-	// Store the quota and usage since it applies to us
-	iter.quota = quota
-	iter.quotaLimits = quota.LimitsMap()
-	iter.actUsage = usage
+	result.usage = &structs.QuotaUsage{Used: used}
+	return result, nil
+}
+
+// hasQuota reports whether any namespace-scoped or cluster-wide quota
+// applies to the job currently set on the iterator.
+func (iter *QuotaIterator) hasQuota() bool {
+	return iter.quota != nil || len(iter.clusterQuotaNames) > 0
 }
 
 // Next implements the Next function of the FeasibleIterator interface. In the
@@ -116,30 +232,62 @@ func (iter *QuotaIterator) Next() *structs.Node {
 
 	// If there is no quota or there was an error building the iterator so
 	// just act as a pass through.
-	if option == nil || iter.quota == nil || iter.buildErr != nil {
+	if option == nil || !iter.hasQuota() || iter.buildErr != nil {
 		return option
 	}
 
-	// Add the resources of the proposed task group. It is important to use a
-	// copy, as the node may get rejected later during the selection process
-	// and Next() called numerous times before Reset().
-	proposedLimitCopy := iter.proposedLimit.Copy()
-	proposedLimitCopy.AddResource(iter.combinedResources)
+	// Check every limit for the job's region whose scope applies to the task
+	// group being placed. A job may be constrained by more than one limit at
+	// once (for example a region-wide limit and a narrower batch-only limit).
+	var dimensions []string
+	reached := make(map[string]struct{})
+	for hash, limit := range iter.proposedLimits {
+		if limit.Region != "" && limit.Region != iter.job.Region {
+			continue
+		}
+		if !limit.Scope.Matches(iter.job, iter.taskGroup, iter.namespace) {
+			continue
+		}
+
+		// Add the resources of the proposed task group. It is important to
+		// use a copy, as the node may get rejected later during the
+		// selection process and Next() called numerous times before Reset().
+		proposedLimitCopy := limit.Copy()
+		proposedLimitCopy.AddResource(iter.combinedResources)
+		proposedLimitCopy.AddCounts(iter.combinedCounts)
 
-	// Get the actual limit
-	quotaLimit := iter.quotaLimits[string(proposedLimitCopy.Hash)]
+		// Get the actual limit
+		quotaLimit := iter.quotaLimits[hash]
 
-	superset, dimensions := quotaLimit.Superset(proposedLimitCopy)
-	if superset {
+		superset, exceeded := quotaLimit.Superset(proposedLimitCopy)
+		if !superset {
+			dimensions = append(dimensions, exceeded...)
+			reached[iter.quotaNameFor(hash)] = struct{}{}
+			continue
+		}
+
+		// The placement still fits under the hard cap, but may have pushed
+		// usage past the soft warning threshold. This does not block
+		// placement, only surfaces a warning.
+		if soft := quotaLimit.SoftExceeded(proposedLimitCopy); len(soft) > 0 {
+			iter.ctx.Metrics().QuotaSoftExceeded(soft)
+			iter.ctx.Eligibility().SetQuotaSoftLimitReached(iter.quotaNameFor(hash))
+		}
+	}
+
+	if len(dimensions) == 0 {
 		return option
 	}
 
 	// Mark the dimensions that caused the quota to be exhausted
 	iter.ctx.Metrics().ExhaustQuota(dimensions)
 
-	// Store the fact that the option was rejected because the quota limit was
-	// reached.
-	iter.ctx.Eligibility().SetQuotaLimitReached(iter.quota.Name)
+	// Store the fact that the option was rejected because a quota limit was
+	// reached, whether it came from the namespace's own quota or one of the
+	// cluster quotas aggregating across namespaces.
+	for name := range reached {
+		iter.ctx.Eligibility().SetQuotaLimitReached(name)
+	}
 
 	return nil
 }
@@ -151,18 +299,20 @@ func (iter *QuotaIterator) Reset() {
 	iter.source.Reset()
 
 	// There is nothing more to do
-	if iter.quota == nil {
+	if !iter.hasQuota() {
 		return
 	}
 
-	// Populate the quota usage with proposed allocations
+	// Populate the quota usage with proposed allocations. Only allocations
+	// whose job/task group match a limit's scope contribute to that limit's
+	// running total.
 	iter.proposedUsage = iter.actUsage.Copy()
-	structs.UpdateUsageFromPlan(iter.proposedUsage, iter.ctx.Plan())
+	structs.UpdateUsageFromPlan(iter.proposedUsage, iter.ctx.Plan(), iter.namespace)
 
-	// At this point there will be only one limit and it will apply.
-	for _, l := range iter.proposedUsage.Used {
-		iter.proposedLimit = l
-	}
+	// proposedLimits holds every limit that could possibly apply; Next()
+	// further narrows this to the limits for the job's region whose scope
+	// matches the task group currently being placed.
+	iter.proposedLimits = iter.proposedUsage.Used
 }
 
 // combinedResources returns the combined resources for the task group
@@ -174,4 +324,46 @@ func combinedResources(tg *structs.TaskGroup) *structs.Resources {
 		r.Add(task.Resources)
 	}
 	return r
+}
+
+// combinedCounts returns the object counts contributed by placing one more
+// allocation of the task group, keyed by the QuotaCount* constants. Reserved
+// and dynamic ports across the group's own Networks and every task count
+// toward QuotaCountHostPorts. QuotaCountTaskGroups is not included here since
+// it counts distinct groups rather than allocations; it is credited in
+// structs.UpdateUsageFromPlan once per (job, group) pair instead.
+func combinedCounts(tg *structs.TaskGroup) map[string]int {
+	counts := map[string]int{
+		structs.QuotaCountAllocations: 1,
+	}
+
+	ports := 0
+	for _, n := range tg.Networks {
+		ports += len(n.ReservedPorts) + len(n.DynamicPorts)
+	}
+	for _, task := range tg.Tasks {
+		if task.Resources == nil {
+			continue
+		}
+		for _, n := range task.Resources.Networks {
+			ports += len(n.ReservedPorts) + len(n.DynamicPorts)
+		}
+	}
+	if ports > 0 {
+		counts[structs.QuotaCountHostPorts] = ports
+	}
+
+	services := len(tg.Services)
+	for _, task := range tg.Tasks {
+		services += len(task.Services)
+	}
+	if services > 0 {
+		counts[structs.QuotaCountServices] = services
+	}
+
+	if len(tg.Volumes) > 0 {
+		counts[structs.QuotaCountVolumes] = len(tg.Volumes)
+	}
+
+	return counts
 }
\ No newline at end of file